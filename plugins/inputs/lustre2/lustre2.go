@@ -0,0 +1,912 @@
+//go:build linux
+
+// Package lustre2 implements a Telegraf input plugin that reads performance
+// counters exposed by the Lustre parallel filesystem under /proc and
+// /sys/kernel/debug on OSS, MDS and client nodes.
+package lustre2
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/inputs"
+	"gopkg.in/yaml.v2"
+)
+
+// now is the wall-clock source used to time EmitRates; it is a package
+// variable, rather than a direct time.Now() call, so tests can
+// substitute a fake clock.
+var now = time.Now
+
+// measurement is the name under which the bulk of the OST/MDT/client/lnet
+// counters are published.
+const measurement = "lustre2"
+
+// brwMeasurement holds the brw_stats histograms, which are keyed
+// differently (one point per histogram bucket) and so are kept separate
+// from the scalar counters in measurement.
+const brwMeasurement = "lustre2_brw"
+
+// lliteMeasurement holds the client-side mount stats gathered from
+// llite_procfiles, kept separate from measurement since they are tagged
+// by mount rather than by OST/MDT name.
+const lliteMeasurement = "lustre2_client"
+
+// healthMeasurement holds the recovery_status and health_check data,
+// kept separate from measurement since it is tagged by status rather
+// than purely by target name.
+const healthMeasurement = "lustre2_health"
+
+// Lustre2 is a Telegraf input plugin that gathers local Lustre service
+// stats.
+type Lustre2 struct {
+	OstProcfiles          []string `toml:"ost_procfiles"`
+	MdsProcfiles          []string `toml:"mds_procfiles"`
+	LnetProcfiles         []string `toml:"lnet_procfiles"`
+	BrwStatsProcfiles     []string `toml:"brw_stats_procfiles"`
+	LliteProcfiles        []string `toml:"llite_procfiles"`
+	ExtentsStatsProcfiles []string `toml:"llite_extents_stats_procfiles"`
+	RecoveryStatusFiles   []string `toml:"recovery_status_files"`
+	HealthCheckFile       string   `toml:"health_check_file"`
+	IncludeZeroBuckets    bool     `toml:"include_zero_buckets"`
+	AutoDiscover          bool     `toml:"auto_discover"`
+	EmitRates             bool     `toml:"emit_rates"`
+
+	// points accumulates the data gathered from every proc file touched
+	// during a single Gather call, keyed by measurement+tag set, so that
+	// several files contributing to the same target (e.g. obdfilter and
+	// osd-ldiskfs stats for one OST) end up in a single point.
+	points map[string]*lustrePoint
+
+	// rates holds the previous Gather's snapshot of every monotonic
+	// counter, keyed by measurement+tag set+field, so that EmitRates can
+	// compute a per-second rate across calls.
+	rates map[string]rateSample
+}
+
+// rateSample is one previously observed counter value, used by EmitRates
+// to compute (current - previous) / seconds_since_last_gather.
+type rateSample struct {
+	value     uint64
+	timestamp time.Time
+}
+
+// lustrePoint is one not-yet-emitted measurement point.
+type lustrePoint struct {
+	measurement string
+	tags        map[string]string
+	fields      map[string]interface{}
+}
+
+var sampleConfig = `
+  ## An array of /proc globs to search for Lustre stats. At least one of
+  ## ost_procfiles/mds_procfiles must be set, or auto_discover enabled,
+  ## or no OST/MDT metrics will be gathered.
+  ##
+  # ost_procfiles = [
+  #   "/proc/fs/lustre/obdfilter/*/stats",
+  #   "/proc/fs/lustre/osd-ldiskfs/*/stats",
+  #   "/proc/fs/lustre/obdfilter/*/job_stats",
+  # ]
+  # mds_procfiles = [
+  #   "/proc/fs/lustre/mdt/*/md_stats",
+  # ]
+  # lnet_procfiles = [
+  #   "/sys/kernel/debug/lnet/stats",
+  # ]
+
+  ## An array of /proc globs to search for Lustre brw_stats histograms
+  ## (bulk read/write RPC size and latency distributions per OST).
+  # brw_stats_procfiles = [
+  #   "/proc/fs/lustre/obdfilter/*/brw_stats",
+  #   "/sys/kernel/debug/lustre/osd-ldiskfs/*/brw_stats",
+  # ]
+
+  ## Report histogram buckets whose read and write counts are both zero.
+  ## By default these are skipped to keep cardinality down.
+  # include_zero_buckets = false
+
+  ## An array of /proc globs to search for Lustre client (llite) mount
+  ## stats.
+  # llite_procfiles = [
+  #   "/proc/fs/lustre/llite/*/stats",
+  # ]
+
+  ## An array of /proc globs to search for Lustre client (llite)
+  ## extents_stats histograms (read/write RPC size distribution per
+  ## mount).
+  # llite_extents_stats_procfiles = [
+  #   "/sys/kernel/debug/lustre/llite/*/extents_stats",
+  # ]
+
+  ## Probe the legacy /proc/fs/lustre procfs tree and the /sys/fs/lustre
+  ## and /sys/kernel/debug/lustre sysfs trees used by Lustre 2.12+ for
+  ## OST and MDT targets, merging anything found that is not already
+  ## covered by ost_procfiles/mds_procfiles. Off by default to preserve
+  ## existing behaviour.
+  # auto_discover = false
+
+  ## An array of /proc globs to search for Lustre recovery_status files,
+  ## e.g. /proc/fs/lustre/{obdfilter,mdt}/*/recovery_status.
+  # recovery_status_files = [
+  #   "/proc/fs/lustre/obdfilter/*/recovery_status",
+  #   "/proc/fs/lustre/mdt/*/recovery_status",
+  # ]
+
+  ## Path to the global Lustre health_check file.
+  # health_check_file = "/proc/fs/lustre/health_check"
+
+  ## Keep the previous Gather's counters in memory and emit an
+  ## additional <field>_per_sec field computed from the delta since the
+  ## last Gather, for every counter that only increases. A counter that
+  ## has decreased since the previous Gather (e.g. it was reset) is
+  ## skipped for that cycle rather than reported as a negative rate.
+  # emit_rates = false
+`
+
+// SampleConfig returns sample configuration message.
+func (l *Lustre2) SampleConfig() string {
+	return sampleConfig
+}
+
+// Description returns description of Lustre2 plugin.
+func (l *Lustre2) Description() string {
+	return "Read metrics from local Lustre service on OST, MDS"
+}
+
+// wantedOstFields maps the name of a bytes-counter line in a Lustre
+// "stats" file to the field prefix it should be published under. Those
+// lines carry both a sample count and a sum, so they expand into two
+// fields (e.g. read_bytes -> read_calls, read_bytes).
+var wantedOstFields = map[string]string{
+	"read_bytes":  "read",
+	"write_bytes": "write",
+}
+
+// parseStatsFile turns the contents of a Lustre "stats"-style proc file
+// (obdfilter/*/stats, osd-ldiskfs/*/stats, mdt/*/md_stats, or the
+// per-export stats under exports/*/stats) into a flat field map. Every
+// line is either a plain request counter:
+//
+//	open                      1024577037 samples [reqs]
+//
+// or a byte counter with a sample count, min, max and sum:
+//
+//	read_bytes                203238095 samples [bytes] 4096 1048576 78026117632000
+func parseStatsFile(data []byte) map[string]interface{} {
+	fields := make(map[string]interface{})
+	for _, line := range strings.Split(string(data), "\n") {
+		parts := strings.Fields(line)
+		if len(parts) < 2 {
+			continue
+		}
+		name := parts[0]
+		if name == "snapshot_time" {
+			continue
+		}
+
+		if prefix, ok := wantedOstFields[name]; ok {
+			if len(parts) < 7 {
+				continue
+			}
+			samples, err := strconv.ParseUint(parts[1], 10, 64)
+			if err != nil {
+				continue
+			}
+			sum, err := strconv.ParseUint(parts[6], 10, 64)
+			if err != nil {
+				continue
+			}
+			fields[prefix+"_calls"] = samples
+			fields[prefix+"_bytes"] = sum
+			continue
+		}
+
+		samples, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		fields[name] = samples
+	}
+	return fields
+}
+
+// lnetStatFields lists, in file order, the field names published from
+// /sys/kernel/debug/lnet/stats (formerly /proc/sys/lnet/stats):
+//
+//	msgs_alloc msgs_max rst_alloc send_count recv_count route_count drop_count \
+//	    send_length recv_length route_length drop_length
+var lnetStatFields = []string{
+	"lnet_msgs_alloc",
+	"lnet_msgs_max",
+	"lnet_rst_alloc",
+	"lnet_send_count",
+	"lnet_recv_count",
+	"lnet_route_count",
+	"lnet_drop_count",
+	"lnet_send_length",
+	"lnet_recv_length",
+	"lnet_route_length",
+	"lnet_drop_length",
+}
+
+// parseLnetStats parses the single-line lnet stats file.
+func parseLnetStats(data []byte) (map[string]interface{}, error) {
+	parts := strings.Fields(string(data))
+	if len(parts) < len(lnetStatFields) {
+		return nil, fmt.Errorf("lustre2: lnet stats: expected %d fields, got %d", len(lnetStatFields), len(parts))
+	}
+
+	fields := make(map[string]interface{}, len(lnetStatFields))
+	for i, name := range lnetStatFields {
+		value, err := strconv.ParseUint(parts[i], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("lustre2: lnet stats: %w", err)
+		}
+		fields[name] = value
+	}
+	return fields, nil
+}
+
+// wantedOstJobstatsFields maps a byte-counter job_stats key to the
+// field prefix it expands into (see parseStatsFile for the non-jobstats
+// equivalent).
+var wantedOstJobstatsFields = map[string]string{
+	"read_bytes":  "read",
+	"write_bytes": "write",
+}
+
+// jobStatsCounter is one job_stats counter. Lustre writes these both as
+// a single-line flow mapping (`{ samples: 1, unit: bytes, min: ... }`)
+// and, on some versions, as a multi-line block mapping; both decode into
+// the same struct.
+type jobStatsCounter struct {
+	Samples uint64 `yaml:"samples"`
+	Min     uint64 `yaml:"min"`
+	Max     uint64 `yaml:"max"`
+	Sum     uint64 `yaml:"sum"`
+}
+
+// jobStatsEntry is one job's entry in a job_stats file. job_id and
+// snapshot_time are named explicitly so that the ",inline" map only
+// picks up the actual counters.
+type jobStatsEntry struct {
+	JobID        string                     `yaml:"job_id"`
+	SnapshotTime int64                      `yaml:"snapshot_time"`
+	Counters     map[string]jobStatsCounter `yaml:",inline"`
+}
+
+// jobStatsFile is the top-level document of a job_stats proc/sysfs file.
+type jobStatsFile struct {
+	Jobs []jobStatsEntry `yaml:"job_stats"`
+}
+
+// jobstatsParser decodes a job_stats file into its per-job entries;
+// parseJobStats then maps each entry's counters onto field names.
+type jobstatsParser struct{}
+
+func (jobstatsParser) parse(data []byte) ([]jobStatsEntry, error) {
+	var doc jobStatsFile
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("lustre2: job_stats: %w", err)
+	}
+	return doc.Jobs, nil
+}
+
+// parseJobStats decodes the "job_stats:" proc/sysfs file emitted by OSTs
+// and MDTs and adds one lustre2 point per job, tagged with both the
+// target name and the job id.
+func (l *Lustre2) parseJobStats(data []byte, name string) {
+	jobs, err := (jobstatsParser{}).parse(data)
+	if err != nil {
+		return
+	}
+
+	for _, job := range jobs {
+		if job.JobID == "" {
+			continue
+		}
+
+		fields := make(map[string]interface{}, len(job.Counters))
+		for key, counter := range job.Counters {
+			if prefix, ok := wantedOstJobstatsFields[key]; ok {
+				fields["jobstats_"+prefix+"_calls"] = counter.Samples
+				fields["jobstats_"+prefix+"_min_size"] = counter.Min
+				fields["jobstats_"+prefix+"_max_size"] = counter.Max
+				fields["jobstats_"+prefix+"_bytes"] = counter.Sum
+				continue
+			}
+			fields["jobstats_"+key] = counter.Samples
+		}
+
+		l.addFields(measurement, map[string]string{
+			"name":  name,
+			"jobid": job.JobID,
+		}, fields)
+	}
+}
+
+// brwSections maps the label that introduces each brw_stats block to the
+// "histogram" tag value it is published under.
+var brwSections = []struct {
+	label string
+	name  string
+}{
+	{"pages per bulk r/w", "pages_per_bulk_rw"},
+	{"discontiguous pages", "discontiguous_pages"},
+	{"discontiguous blocks", "discontiguous_blocks"},
+	{"disk fragments", "disk_fragments"},
+	{"disk I/Os in flight", "disk_ios_in_flight"},
+	{"I/O time", "io_time"},
+	{"disk I/O size", "disk_io_size"},
+}
+
+// brwLineRE matches a single brw_stats histogram bucket line. Lustre
+// reports a count, a percentage and a running cumulative percentage on
+// each side of the "|"; only the counts are kept.
+//
+//	1:		       2007  50  50   |        1000  33  33
+var brwLineRE = regexp.MustCompile(`^\s*(\S+):\s+(\d+)\s+\d+\s+\d+\s*\|\s*(\d+)\s+\d+\s+\d+\s*$`)
+
+// brwSection returns the histogram name for a section-header line, or ""
+// if the line does not introduce a new section.
+func brwSection(line string) string {
+	for _, s := range brwSections {
+		if strings.Contains(line, s.label) {
+			return s.name
+		}
+	}
+	return ""
+}
+
+// parseBrwStats walks a brw_stats file and adds one lustre2_brw point per
+// non-empty histogram bucket (or every bucket, if IncludeZeroBuckets is
+// set), tagged with the OST name, the histogram section and the bucket.
+func (l *Lustre2) parseBrwStats(data []byte, name string) {
+	section := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if s := brwSection(line); s != "" {
+			section = s
+			continue
+		}
+		if section == "" {
+			continue
+		}
+
+		m := brwLineRE.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		readCount, err := strconv.ParseUint(m[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		writeCount, err := strconv.ParseUint(m[3], 10, 64)
+		if err != nil {
+			continue
+		}
+		if readCount == 0 && writeCount == 0 && !l.IncludeZeroBuckets {
+			continue
+		}
+
+		l.addFields(brwMeasurement, map[string]string{
+			"name":      name,
+			"histogram": section,
+			"bucket":    m[1],
+		}, map[string]interface{}{
+			"read_count":  readCount,
+			"write_count": writeCount,
+		})
+	}
+}
+
+// tagID returns a stable string key for a tag set, suitable for use as a
+// map key when merging fields gathered from several proc files that
+// describe the same target.
+func tagID(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+"="+tags[k])
+	}
+	return strings.Join(parts, ",")
+}
+
+// addFields merges fields into the point identified by measurement+tags,
+// creating it if this is the first time it has been seen this Gather
+// call.
+func (l *Lustre2) addFields(measurement string, tags map[string]string, fields map[string]interface{}) {
+	key := measurement + "|" + tagID(tags)
+	p, ok := l.points[key]
+	if !ok {
+		p = &lustrePoint{
+			measurement: measurement,
+			tags:        tags,
+			fields:      make(map[string]interface{}),
+		}
+		l.points[key] = p
+	}
+	for k, v := range fields {
+		p.fields[k] = v
+	}
+}
+
+// isRateField reports whether field is a monotonic counter that it makes
+// sense to compute a per-second rate for. lustre2_health's fields are
+// either point-in-time gauges (connected_clients, time_remaining) or a
+// timestamp (recovery_start), not counters, so that whole measurement is
+// excluded; jobstats_*_min_size/jobstats_*_max_size are window extrema
+// rather than cumulative counters, so they are excluded too.
+func isRateField(measurement, field string) bool {
+	if measurement == healthMeasurement {
+		return false
+	}
+	return !strings.HasSuffix(field, "_min_size") && !strings.HasSuffix(field, "_max_size")
+}
+
+// applyRates adds a <field>_per_sec field to every monotonic-counter
+// uint64 field in l.points (see isRateField), computed against the value
+// recorded for that field the last time applyRates ran. Fields seen for
+// the first time, or whose value has decreased (a counter reset), are
+// left without a rate this cycle.
+func (l *Lustre2) applyRates(ts time.Time) {
+	if l.rates == nil {
+		l.rates = make(map[string]rateSample)
+	}
+
+	for _, p := range l.points {
+		base := p.measurement + "|" + tagID(p.tags)
+		additions := make(map[string]float64)
+
+		for field, v := range p.fields {
+			if !isRateField(p.measurement, field) {
+				continue
+			}
+			current, ok := v.(uint64)
+			if !ok {
+				continue
+			}
+
+			key := base + "|" + field
+			prev, seen := l.rates[key]
+			l.rates[key] = rateSample{value: current, timestamp: ts}
+			if !seen || current < prev.value {
+				continue
+			}
+
+			elapsed := ts.Sub(prev.timestamp).Seconds()
+			if elapsed <= 0 {
+				continue
+			}
+			additions[field+"_per_sec"] = float64(current-prev.value) / elapsed
+		}
+
+		for field, rate := range additions {
+			p.fields[field] = rate
+		}
+	}
+}
+
+// wildcardValues returns, for each "*" segment in pattern, the matching
+// path segment found at the same position in path. path must be a result
+// of filepath.Glob(pattern), so the two have the same number of path
+// segments once both are cleaned - filepath.Glob always returns clean,
+// single-separator paths, but pattern may not be (e.g. a config built by
+// concatenating a directory that already ends in "/" with a leading-"/"
+// glob), so pattern is cleaned here to match.
+func wildcardValues(pattern, path string) []string {
+	patParts := strings.Split(filepath.Clean(pattern), string(filepath.Separator))
+	pathParts := strings.Split(filepath.Clean(path), string(filepath.Separator))
+
+	var values []string
+	for i, p := range patParts {
+		if p == "*" && i < len(pathParts) {
+			values = append(values, pathParts[i])
+		}
+	}
+	return values
+}
+
+// knownProcRoots are the path prefixes under which the legacy procfs tree
+// and the 2.12+ sysfs trees expose the same counters. patternRoot strips
+// whichever of these prefixes a pattern starts with, so that e.g.
+// "/proc/fs/lustre/obdfilter/*/stats" and
+// "/sys/fs/lustre/obdfilter/*/stats" normalize to the same root
+// ("obdfilter/*/stats") while "osd-ldiskfs/*/stats" stays distinct.
+var knownProcRoots = []string{
+	"/proc/fs/lustre/",
+	"/sys/fs/lustre/",
+	"/sys/kernel/debug/lustre/",
+}
+
+// patternRoot returns pattern with any known legacy/sysfs prefix
+// stripped off, falling back to pattern unchanged for anything else
+// (e.g. user-supplied paths outside those trees). This is the
+// granularity at which gatherProcfiles dedupes targets: patterns that
+// normalize to the same root describe the same counters exposed two
+// ways, while patterns with different roots (obdfilter/stats vs
+// osd-ldiskfs/stats, stats vs job_stats) describe different counters
+// that must still be merged together, not deduped.
+func patternRoot(pattern string) string {
+	for _, prefix := range knownProcRoots {
+		if strings.HasPrefix(pattern, prefix) {
+			return strings.TrimPrefix(pattern, prefix)
+		}
+	}
+	return pattern
+}
+
+// procResolver lists the glob roots that describe where an OST/MDT
+// subsystem's counters live: the legacy /proc/fs/lustre tree used by
+// Lustre 2.11 and earlier, and the /sys/fs/lustre and
+// /sys/kernel/debug/lustre trees that 2.12+ moved most counters to.
+// Keeping the roots in package variables, rather than inlining them in
+// Gather, lets tests point discovery at a fake root.
+type procResolver struct {
+	legacy []string
+	sysfs  []string
+}
+
+// patterns returns every glob this resolver should search.
+func (r procResolver) patterns() []string {
+	return append(append([]string{}, r.legacy...), r.sysfs...)
+}
+
+var ostDiscoveryRoots = procResolver{
+	legacy: []string{
+		"/proc/fs/lustre/obdfilter/*/stats",
+		"/proc/fs/lustre/osd-ldiskfs/*/stats",
+	},
+	sysfs: []string{
+		"/sys/fs/lustre/obdfilter/*/stats",
+		"/sys/kernel/debug/lustre/osd-ldiskfs/*/stats",
+	},
+}
+
+var mdsDiscoveryRoots = procResolver{
+	legacy: []string{
+		"/proc/fs/lustre/mdt/*/md_stats",
+	},
+	sysfs: []string{
+		"/sys/fs/lustre/mdt/*/md_stats",
+	},
+}
+
+// gatherProcfiles globs every pattern and adds the stats it finds,
+// dispatching job_stats files to parseJobStats and everything else to
+// parseStatsFile. seen records the (patternRoot, target) of every file
+// processed; a combination already present in seen is skipped, which is
+// what lets AutoDiscover merge the legacy and sysfs roots without
+// double-counting a target exposed under both. Patterns with different
+// roots - obdfilter/stats vs osd-ldiskfs/stats, or stats vs job_stats -
+// always have distinct keys, so they merge into the target's point
+// instead of shadowing each other.
+func (l *Lustre2) gatherProcfiles(patterns []string, seen map[string]bool) error {
+	for _, pattern := range patterns {
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return err
+		}
+
+		root := patternRoot(pattern)
+		for _, file := range files {
+			values := wildcardValues(pattern, file)
+			if len(values) == 0 {
+				continue
+			}
+			name := values[0]
+			dedupKey := root + "\x00" + strings.Join(values, "\x00")
+			if seen[dedupKey] {
+				continue
+			}
+			seen[dedupKey] = true
+
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+
+			if filepath.Base(file) == "job_stats" {
+				l.parseJobStats(data, name)
+				continue
+			}
+
+			tags := map[string]string{"name": name}
+			if strings.Contains(pattern, "/exports/") && len(values) > 1 {
+				tags["client"] = values[1]
+			}
+			l.addFields(measurement, tags, parseStatsFile(data))
+		}
+	}
+	return nil
+}
+
+// gatherLliteStats globs pattern and adds the client-side mount stats it
+// finds, using the same stats-file tokenizer as gatherProcfiles. Each
+// mount's proc directory is named after its fsname-uuid, e.g.
+// "lustre-ffff8801deadbeef".
+func (l *Lustre2) gatherLliteStats(pattern string) error {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		values := wildcardValues(pattern, file)
+		if len(values) == 0 {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		l.addFields(lliteMeasurement, map[string]string{"mount": values[0]}, parseStatsFile(data))
+	}
+	return nil
+}
+
+// extentsStatsLineRE matches a single extents_stats histogram bucket
+// line, e.g.:
+//
+//	0K - 4K        :             12     40    40   |          20     33    33
+var extentsStatsLineRE = regexp.MustCompile(`^\s*(\S.*?\S)\s*:\s*(\d+)\s+\d+\s+\d+\s*\|\s*(\d+)\s+\d+\s+\d+\s*$`)
+
+// parseExtentsStats walks an extents_stats file and adds one
+// lustre2_client point per non-empty histogram bucket (or every bucket,
+// if IncludeZeroBuckets is set), tagged with the mount and the bucket's
+// RPC size range.
+func (l *Lustre2) parseExtentsStats(data []byte, mount string) {
+	for _, line := range strings.Split(string(data), "\n") {
+		m := extentsStatsLineRE.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		readCount, err := strconv.ParseUint(m[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		writeCount, err := strconv.ParseUint(m[3], 10, 64)
+		if err != nil {
+			continue
+		}
+		if readCount == 0 && writeCount == 0 && !l.IncludeZeroBuckets {
+			continue
+		}
+
+		l.addFields(lliteMeasurement, map[string]string{
+			"mount":  mount,
+			"bucket": m[1],
+		}, map[string]interface{}{
+			"read_count":  readCount,
+			"write_count": writeCount,
+		})
+	}
+}
+
+// gatherExtentsStats globs pattern and adds the extents_stats histogram
+// of every client mount it finds.
+func (l *Lustre2) gatherExtentsStats(pattern string) error {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		values := wildcardValues(pattern, file)
+		if len(values) == 0 {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		l.parseExtentsStats(data, values[0])
+	}
+	return nil
+}
+
+// recoveryStatusLineRE matches a "key: value" line in a recovery_status
+// file, e.g.:
+//
+//	status: COMPLETE
+//	recovery_start: 1461772700
+var recoveryStatusLineRE = regexp.MustCompile(`^\s*(\w+):\s*(.+?)\s*$`)
+
+// parseRecoveryStatus turns a recovery_status file into a lustre2_health
+// point tagged by target name and recovery status (COMPLETE, RECOVERING
+// or WAITING), with one integer field per numeric recovery counter.
+func (l *Lustre2) parseRecoveryStatus(data []byte, name string) {
+	tags := map[string]string{"name": name}
+	fields := make(map[string]interface{})
+
+	for _, line := range strings.Split(string(data), "\n") {
+		m := recoveryStatusLineRE.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		key, value := m[1], m[2]
+		if key == "status" {
+			tags["status"] = value
+			continue
+		}
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		fields[key] = n
+	}
+
+	if _, ok := tags["status"]; !ok {
+		return
+	}
+	l.addFields(healthMeasurement, tags, fields)
+}
+
+// gatherRecoveryStatus globs pattern and adds the recovery_status of
+// every target it finds.
+func (l *Lustre2) gatherRecoveryStatus(pattern string) error {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		values := wildcardValues(pattern, file)
+		if len(values) == 0 {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		l.parseRecoveryStatus(data, values[0])
+	}
+	return nil
+}
+
+// gatherHealthCheck reads the global health_check file, if configured,
+// and adds its "healthy" boolean as its own lustre2_health point.
+func (l *Lustre2) gatherHealthCheck(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	healthy := strings.TrimSpace(string(data)) == "healthy"
+	l.addFields(healthMeasurement, map[string]string{"name": "health_check"}, map[string]interface{}{
+		"healthy": healthy,
+	})
+	return nil
+}
+
+// gatherLnetStats globs pattern and adds the lnet counters it finds.
+func (l *Lustre2) gatherLnetStats(pattern string) error {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		fields, err := parseLnetStats(data)
+		if err != nil {
+			return err
+		}
+		l.addFields(measurement, map[string]string{"name": "lnet"}, fields)
+	}
+	return nil
+}
+
+// gatherBrwStats globs pattern and adds the brw_stats histograms it
+// finds.
+func (l *Lustre2) gatherBrwStats(pattern string) error {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		values := wildcardValues(pattern, file)
+		if len(values) == 0 {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		l.parseBrwStats(data, values[0])
+	}
+	return nil
+}
+
+// Gather reads the stats files configured on Lustre2 and emits the
+// metrics they describe.
+func (l *Lustre2) Gather(acc telegraf.Accumulator) error {
+	l.points = make(map[string]*lustrePoint)
+
+	seenOst := make(map[string]bool)
+	if err := l.gatherProcfiles(l.OstProcfiles, seenOst); err != nil {
+		return err
+	}
+	if l.AutoDiscover {
+		if err := l.gatherProcfiles(ostDiscoveryRoots.patterns(), seenOst); err != nil {
+			return err
+		}
+	}
+
+	seenMds := make(map[string]bool)
+	if err := l.gatherProcfiles(l.MdsProcfiles, seenMds); err != nil {
+		return err
+	}
+	if l.AutoDiscover {
+		if err := l.gatherProcfiles(mdsDiscoveryRoots.patterns(), seenMds); err != nil {
+			return err
+		}
+	}
+
+	for _, pattern := range l.LnetProcfiles {
+		if err := l.gatherLnetStats(pattern); err != nil {
+			return err
+		}
+	}
+	for _, pattern := range l.BrwStatsProcfiles {
+		if err := l.gatherBrwStats(pattern); err != nil {
+			return err
+		}
+	}
+	for _, pattern := range l.LliteProcfiles {
+		if err := l.gatherLliteStats(pattern); err != nil {
+			return err
+		}
+	}
+	for _, pattern := range l.ExtentsStatsProcfiles {
+		if err := l.gatherExtentsStats(pattern); err != nil {
+			return err
+		}
+	}
+	for _, pattern := range l.RecoveryStatusFiles {
+		if err := l.gatherRecoveryStatus(pattern); err != nil {
+			return err
+		}
+	}
+	if err := l.gatherHealthCheck(l.HealthCheckFile); err != nil {
+		return err
+	}
+
+	if l.EmitRates {
+		l.applyRates(now())
+	}
+
+	for _, p := range l.points {
+		acc.AddFields(p.measurement, p.fields, p.tags)
+	}
+	return nil
+}
+
+func init() {
+	inputs.Add("lustre2", func() telegraf.Input {
+		return &Lustre2{}
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 	"github.com/stretchr/testify/require"
 	"github.com/influxdata/toml"
 	"github.com/influxdata/toml/ast"
@@ -250,6 +251,177 @@ func TestLustre2GeneratesJobstatsMetrics(t *testing.T) {
 	}
 }
 
+/*
+ * Test: TestLustre2GeneratesJobstatsMetricsMultiline
+ * Purpose: Verify job_stats files with more than two jobs and a mix of
+ *          flow-style and multi-line block-style YAML counters.
+ * TestFolder: testcases/TestLustre2GeneratesJobstatsMetrics
+ * InputFile: mdtJobStatsContentsMultiline.out
+ * InputFile: obdfilterJobStatsContentsMultiline.out
+ */
+func TestLustre2GeneratesJobstatsMetricsMultiline(t *testing.T) {
+	data := ReadDir(t, "testcases",
+			"TestLustre2GeneratesJobstatsMetrics",
+			"mdtJobStatsContentsMultiline.out")
+	data1 := ReadDir(t, "testcases",
+			"TestLustre2GeneratesJobstatsMetrics",
+			"obdfilterJobStatsContentsMultiline.out")
+
+	tmpDir := makeTempDir(t, "telegraf-lustre-jobstats-multiline")
+	tempdir := tmpDir + "/telegraf/proc/fs/lustre/"
+	ostName := "OST0002"
+	jobNames := []string{"job-one", "job-two", "job-three"}
+
+	mdtdir := tempdir + "/mdt/"
+	err := os.MkdirAll(mdtdir+"/"+ostName, 0750)
+	require.NoError(t, err)
+
+	obddir := tempdir + "/obdfilter/"
+	err = os.MkdirAll(obddir+"/"+ostName, 0750)
+	require.NoError(t, err)
+
+	err = os.WriteFile(mdtdir+"/"+ostName+"/job_stats", []byte(data), 0640)
+	require.NoError(t, err)
+
+	err = os.WriteFile(obddir+"/"+ostName+"/job_stats", []byte(data1), 0640)
+	require.NoError(t, err)
+
+	m := &Lustre2{
+		OstProcfiles: []string{obddir + "/*/job_stats"},
+		MdsProcfiles: []string{mdtdir + "/*/job_stats"},
+	}
+
+	var acc testutil.Accumulator
+
+	err = m.Gather(&acc)
+	require.NoError(t, err)
+
+	tags := []map[string]string{
+		{"name": ostName, "jobid": jobNames[0]},
+		{"name": ostName, "jobid": jobNames[1]},
+		{"name": ostName, "jobid": jobNames[2]},
+	}
+
+	// The mdt and obdfilter job_stats fixtures share the same target
+	// name, so each job's mds and obd counters merge into a single
+	// point, just as TestLustre2GeneratesJobstatsMetrics expects above.
+	fields := []map[string]interface{}{
+		{
+			"jobstats_open":            uint64(3),
+			"jobstats_close":           uint64(3),
+			"jobstats_mknod":           uint64(1),
+			"jobstats_link":            uint64(0),
+			"jobstats_unlink":          uint64(2),
+			"jobstats_mkdir":           uint64(1),
+			"jobstats_rmdir":           uint64(1),
+			"jobstats_rename":          uint64(0),
+			"jobstats_getattr":         uint64(9),
+			"jobstats_setattr":         uint64(0),
+			"jobstats_getxattr":        uint64(2),
+			"jobstats_setxattr":        uint64(1),
+			"jobstats_statfs":          uint64(20),
+			"jobstats_sync":            uint64(0),
+			"jobstats_samedir_rename":  uint64(1),
+			"jobstats_crossdir_rename": uint64(0),
+			"jobstats_read_calls":      uint64(2),
+			"jobstats_read_min_size":   uint64(4096),
+			"jobstats_read_max_size":   uint64(8192),
+			"jobstats_read_bytes":      uint64(12288),
+			"jobstats_write_calls":     uint64(3),
+			"jobstats_write_min_size":  uint64(4096),
+			"jobstats_write_max_size":  uint64(4096),
+			"jobstats_write_bytes":     uint64(12288),
+			"jobstats_ost_getattr":     uint64(0),
+			"jobstats_ost_setattr":     uint64(0),
+			"jobstats_punch":           uint64(0),
+			"jobstats_ost_sync":        uint64(0),
+			"jobstats_destroy":         uint64(0),
+			"jobstats_create":          uint64(0),
+			"jobstats_ost_statfs":      uint64(0),
+			"jobstats_get_info":        uint64(0),
+			"jobstats_set_info":        uint64(0),
+			"jobstats_quotactl":        uint64(0),
+		},
+		{
+			"jobstats_open":            uint64(4),
+			"jobstats_close":           uint64(4),
+			"jobstats_mknod":           uint64(2),
+			"jobstats_link":            uint64(1),
+			"jobstats_unlink":          uint64(3),
+			"jobstats_mkdir":           uint64(2),
+			"jobstats_rmdir":           uint64(2),
+			"jobstats_rename":          uint64(1),
+			"jobstats_getattr":         uint64(10),
+			"jobstats_setattr":         uint64(1),
+			"jobstats_getxattr":        uint64(3),
+			"jobstats_setxattr":        uint64(2),
+			"jobstats_statfs":          uint64(21),
+			"jobstats_sync":            uint64(1),
+			"jobstats_samedir_rename":  uint64(2),
+			"jobstats_crossdir_rename": uint64(1),
+			"jobstats_read_calls":      uint64(3),
+			"jobstats_read_min_size":   uint64(4096),
+			"jobstats_read_max_size":   uint64(12288),
+			"jobstats_read_bytes":      uint64(24576),
+			"jobstats_write_calls":     uint64(4),
+			"jobstats_write_min_size":  uint64(4096),
+			"jobstats_write_max_size":  uint64(8192),
+			"jobstats_write_bytes":     uint64(20480),
+			"jobstats_ost_getattr":     uint64(0),
+			"jobstats_ost_setattr":     uint64(0),
+			"jobstats_punch":           uint64(1),
+			"jobstats_ost_sync":        uint64(0),
+			"jobstats_destroy":         uint64(0),
+			"jobstats_create":          uint64(0),
+			"jobstats_ost_statfs":      uint64(0),
+			"jobstats_get_info":        uint64(0),
+			"jobstats_set_info":        uint64(0),
+			"jobstats_quotactl":        uint64(0),
+		},
+		{
+			"jobstats_open":            uint64(5),
+			"jobstats_close":           uint64(5),
+			"jobstats_mknod":           uint64(3),
+			"jobstats_link":            uint64(2),
+			"jobstats_unlink":          uint64(4),
+			"jobstats_mkdir":           uint64(3),
+			"jobstats_rmdir":           uint64(3),
+			"jobstats_rename":          uint64(2),
+			"jobstats_getattr":         uint64(11),
+			"jobstats_setattr":         uint64(2),
+			"jobstats_getxattr":        uint64(4),
+			"jobstats_setxattr":        uint64(3),
+			"jobstats_statfs":          uint64(22),
+			"jobstats_sync":            uint64(2),
+			"jobstats_samedir_rename":  uint64(3),
+			"jobstats_crossdir_rename": uint64(2),
+			"jobstats_read_calls":      uint64(4),
+			"jobstats_read_min_size":   uint64(4096),
+			"jobstats_read_max_size":   uint64(16384),
+			"jobstats_read_bytes":      uint64(36864),
+			"jobstats_write_calls":     uint64(5),
+			"jobstats_write_min_size":  uint64(4096),
+			"jobstats_write_max_size":  uint64(12288),
+			"jobstats_write_bytes":     uint64(30720),
+			"jobstats_ost_getattr":     uint64(0),
+			"jobstats_ost_setattr":     uint64(0),
+			"jobstats_punch":           uint64(1),
+			"jobstats_ost_sync":        uint64(0),
+			"jobstats_destroy":         uint64(0),
+			"jobstats_create":          uint64(0),
+			"jobstats_ost_statfs":      uint64(0),
+			"jobstats_get_info":        uint64(0),
+			"jobstats_set_info":        uint64(0),
+			"jobstats_quotactl":        uint64(0),
+		},
+	}
+
+	for index := 0; index < len(fields); index++ {
+		acc.AssertContainsTaggedFields(t, "lustre2", fields[index],
+					       tags[index])
+	}
+}
+
 /*
  * Test: TestLustre2GeneratesClientMetrics
  * Purpose: Verify /proc/fs/lustre/mdt/lustre-MDT0000/exports/0\@lo/stats
@@ -455,6 +627,364 @@ func TestLustre2CanParseConfiguration(t *testing.T) {
 		LnetProcfiles: []string{
 			"/sys/kernel/debug/lnet/stats",
 		},
+		LliteProcfiles: []string{
+			"/proc/fs/lustre/llite/*/stats",
+		},
+		ExtentsStatsProcfiles: []string{
+			"/sys/kernel/debug/lustre/llite/*/extents_stats",
+		},
 	}, plugin)
 }
 
+/*
+ * Test: TestLustre2BrwStatsMetrics
+ * Purpose: Verify /proc/fs/lustre/obdfilter/<ost>/brw_stats
+ * TestFolder: testcases/TestLustre2BrwStatsMetrics
+ * InputFile: brw_stats.out
+ */
+func TestLustre2BrwStatsMetrics(t *testing.T) {
+	data := ReadDir(t, "testcases", "TestLustre2BrwStatsMetrics",
+			"brw_stats.out")
+
+	tmpDir := makeTempDir(t, "telegraf-lustre-brw")
+	tempdir := tmpDir + "/telegraf/proc/fs/lustre/obdfilter/"
+	ostName := "OST0001"
+	err := os.MkdirAll(tempdir+"/"+ostName, 0750)
+	require.NoError(t, err)
+
+	err = os.WriteFile(tempdir+"/"+ostName+"/brw_stats", []byte(data), 0640)
+	require.NoError(t, err)
+
+	m := &Lustre2{
+		BrwStatsProcfiles: []string{tempdir + "/*/brw_stats"},
+	}
+
+	var acc testutil.Accumulator
+	err = m.Gather(&acc)
+	require.NoError(t, err)
+
+	acc.AssertContainsTaggedFields(t, "lustre2_brw",
+		map[string]interface{}{
+			"read_count":  uint64(2007),
+			"write_count": uint64(1000),
+		},
+		map[string]string{
+			"name":      ostName,
+			"histogram": "pages_per_bulk_rw",
+			"bucket":    "1",
+		})
+
+	acc.AssertContainsTaggedFields(t, "lustre2_brw",
+		map[string]interface{}{
+			"read_count":  uint64(4014),
+			"write_count": uint64(3000),
+		},
+		map[string]string{
+			"name":      ostName,
+			"histogram": "disk_io_size",
+			"bucket":    "4K",
+		})
+
+	for _, p := range acc.Metrics {
+		if p.Tags["histogram"] == "disk_io_size" && p.Tags["bucket"] == "8K" {
+			t.Fatalf("zero bucket 8K should have been skipped by default")
+		}
+	}
+}
+
+/*
+ * Test: TestLustre2GeneratesLliteMetrics
+ * Purpose: Verify /proc/fs/lustre/llite/<mount>/stats
+ * TestFolder: testcases/TestLustre2GeneratesLliteMetrics
+ * InputFile: lliteProcContents.out
+ */
+func TestLustre2GeneratesLliteMetrics(t *testing.T) {
+	data := ReadDir(t, "testcases", "TestLustre2GeneratesLliteMetrics",
+			"lliteProcContents.out")
+
+	tmpDir := makeTempDir(t, "telegraf-lustre-llite")
+	tempdir := tmpDir + "/telegraf/proc/fs/lustre/llite/"
+	mountName := "lustre-ffff8801deadbeef"
+	err := os.MkdirAll(tempdir+"/"+mountName, 0750)
+	require.NoError(t, err)
+
+	err = os.WriteFile(tempdir+"/"+mountName+"/stats", []byte(data), 0640)
+	require.NoError(t, err)
+
+	m := &Lustre2{
+		LliteProcfiles: []string{tempdir + "/*/stats"},
+	}
+
+	var acc testutil.Accumulator
+	err = m.Gather(&acc)
+	require.NoError(t, err)
+
+	tags := map[string]string{
+		"mount": mountName,
+	}
+
+	fields := map[string]interface{}{
+		"read_calls":  uint64(1845403),
+		"read_bytes":  uint64(592478208000),
+		"write_calls": uint64(923841),
+		"write_bytes": uint64(296239104000),
+		"open":        uint64(58291),
+		"close":       uint64(58290),
+		"ioctl":       uint64(12),
+	}
+
+	acc.AssertContainsTaggedFields(t, "lustre2_client", fields, tags)
+}
+
+/*
+ * Test: TestLustre2ExtentsStatsMetrics
+ * Purpose: Verify /sys/kernel/debug/lustre/llite/<mount>/extents_stats
+ * TestFolder: testcases/TestLustre2ExtentsStatsMetrics
+ * InputFile: extents_stats.out
+ */
+func TestLustre2ExtentsStatsMetrics(t *testing.T) {
+	data := ReadDir(t, "testcases", "TestLustre2ExtentsStatsMetrics",
+			"extents_stats.out")
+
+	tmpDir := makeTempDir(t, "telegraf-lustre-extents")
+	tempdir := tmpDir + "/telegraf/sys/kernel/debug/lustre/llite/"
+	mountName := "lustre-ffff8801deadbeef"
+	err := os.MkdirAll(tempdir+"/"+mountName, 0750)
+	require.NoError(t, err)
+
+	err = os.WriteFile(tempdir+"/"+mountName+"/extents_stats", []byte(data), 0640)
+	require.NoError(t, err)
+
+	m := &Lustre2{
+		ExtentsStatsProcfiles: []string{tempdir + "/*/extents_stats"},
+	}
+
+	var acc testutil.Accumulator
+	err = m.Gather(&acc)
+	require.NoError(t, err)
+
+	acc.AssertContainsTaggedFields(t, "lustre2_client",
+		map[string]interface{}{
+			"read_count":  uint64(12),
+			"write_count": uint64(20),
+		},
+		map[string]string{
+			"mount":  mountName,
+			"bucket": "0K - 4K",
+		})
+
+	acc.AssertContainsTaggedFields(t, "lustre2_client",
+		map[string]interface{}{
+			"read_count":  uint64(0),
+			"write_count": uint64(1),
+		},
+		map[string]string{
+			"mount":  mountName,
+			"bucket": "256K - 512K",
+		})
+
+	for _, p := range acc.Metrics {
+		if p.Tags["mount"] == mountName && p.Tags["bucket"] == "128K - 256K" {
+			t.Fatalf("zero bucket 128K - 256K should have been skipped by default")
+		}
+	}
+}
+
+/*
+ * Test: TestLustre2AutoDiscoverSysfs
+ * Purpose: Verify AutoDiscover picks up an OST exposed only under the
+ *          Lustre 2.12+ /sys/fs/lustre tree, with no ost_procfiles
+ *          configured.
+ * TestFolder: testcases/TestLustre2AutoDiscoverSysfs
+ * InputFile: obdfilterProcContents.out
+ */
+func TestLustre2AutoDiscoverSysfs(t *testing.T) {
+	data := ReadDir(t, "testcases", "TestLustre2AutoDiscoverSysfs",
+			"obdfilterProcContents.out")
+
+	tmpDir := makeTempDir(t, "telegraf-lustre-autodiscover")
+	sysfsDir := tmpDir + "/telegraf/sys/fs/lustre/obdfilter/"
+	ostName := "OST0002"
+	err := os.MkdirAll(sysfsDir+"/"+ostName, 0750)
+	require.NoError(t, err)
+
+	err = os.WriteFile(sysfsDir+"/"+ostName+"/stats", []byte(data), 0640)
+	require.NoError(t, err)
+
+	// Point discovery at the fake root created above instead of the real
+	// /proc and /sys trees.
+	origRoots := ostDiscoveryRoots
+	ostDiscoveryRoots = procResolver{
+		legacy: []string{tmpDir + "/telegraf/proc/fs/lustre/obdfilter/*/stats"},
+		sysfs:  []string{sysfsDir + "/*/stats"},
+	}
+	defer func() { ostDiscoveryRoots = origRoots }()
+
+	m := &Lustre2{AutoDiscover: true}
+
+	var acc testutil.Accumulator
+	err = m.Gather(&acc)
+	require.NoError(t, err)
+
+	tags := map[string]string{
+		"name": ostName,
+	}
+
+	fields := map[string]interface{}{
+		"read_calls":  uint64(203238095),
+		"read_bytes":  uint64(78026117632000),
+		"write_calls": uint64(71893382),
+		"write_bytes": uint64(15201500833981),
+	}
+
+	acc.AssertContainsTaggedFields(t, "lustre2", fields, tags)
+}
+
+
+/*
+ * Test: TestLustre2RecoveryStatusMetrics
+ * Purpose: Verify /proc/fs/lustre/obdfilter/<ost>/recovery_status
+ *          Verify /proc/fs/lustre/health_check
+ * TestFolder: testcases/TestLustre2RecoveryStatusMetrics
+ * InputFile: recovery_status.out
+ * InputFile: health_check.out
+ */
+func TestLustre2RecoveryStatusMetrics(t *testing.T) {
+	data := ReadDir(t, "testcases", "TestLustre2RecoveryStatusMetrics",
+			"recovery_status.out")
+	healthData := ReadDir(t, "testcases", "TestLustre2RecoveryStatusMetrics",
+			"health_check.out")
+
+	tmpDir := makeTempDir(t, "telegraf-lustre-recovery")
+	obddir := tmpDir + "/telegraf/proc/fs/lustre/obdfilter/"
+	ostName := "OST0001"
+	err := os.MkdirAll(obddir+"/"+ostName, 0750)
+	require.NoError(t, err)
+
+	err = os.WriteFile(obddir+"/"+ostName+"/recovery_status", []byte(data), 0640)
+	require.NoError(t, err)
+
+	healthFile := tmpDir + "/telegraf/proc/fs/lustre/health_check"
+	err = os.WriteFile(healthFile, []byte(healthData), 0640)
+	require.NoError(t, err)
+
+	m := &Lustre2{
+		RecoveryStatusFiles: []string{obddir + "/*/recovery_status"},
+		HealthCheckFile:     healthFile,
+	}
+
+	var acc testutil.Accumulator
+	err = m.Gather(&acc)
+	require.NoError(t, err)
+
+	acc.AssertContainsTaggedFields(t, "lustre2_health",
+		map[string]interface{}{
+			"recovery_start":    uint64(1461772700),
+			"time_remaining":    uint64(0),
+			"connected_clients": uint64(12),
+			"req_replayed":      uint64(345),
+		},
+		map[string]string{
+			"name":   ostName,
+			"status": "COMPLETE",
+		})
+
+	acc.AssertContainsTaggedFields(t, "lustre2_health",
+		map[string]interface{}{
+			"healthy": true,
+		},
+		map[string]string{
+			"name": "health_check",
+		})
+}
+
+/*
+ * Test: TestLustre2EmitsRates
+ * Purpose: Verify EmitRates adds a <field>_per_sec field computed from
+ *          the delta between two successive Gather calls, but only for
+ *          monotonic counters - not for lustre2_health's gauges and
+ *          timestamp.
+ * TestFolder: testcases/TestLustre2EmitsRates
+ * InputFile: obdfilterProcContents1.out
+ * InputFile: obdfilterProcContents2.out
+ * InputFile: recovery_status1.out
+ * InputFile: recovery_status2.out
+ */
+func TestLustre2EmitsRates(t *testing.T) {
+	data1 := ReadDir(t, "testcases", "TestLustre2EmitsRates",
+			 "obdfilterProcContents1.out")
+	data2 := ReadDir(t, "testcases", "TestLustre2EmitsRates",
+			 "obdfilterProcContents2.out")
+	recovery1 := ReadDir(t, "testcases", "TestLustre2EmitsRates",
+			 "recovery_status1.out")
+	recovery2 := ReadDir(t, "testcases", "TestLustre2EmitsRates",
+			 "recovery_status2.out")
+
+	tmpDir := makeTempDir(t, "telegraf-lustre-rates")
+	obddir := tmpDir + "/telegraf/proc/fs/lustre/obdfilter/"
+	ostName := "OST0001"
+	err := os.MkdirAll(obddir+"/"+ostName, 0750)
+	require.NoError(t, err)
+
+	statsFile := obddir + "/" + ostName + "/stats"
+	err = os.WriteFile(statsFile, []byte(data1), 0640)
+	require.NoError(t, err)
+
+	recoveryFile := obddir + "/" + ostName + "/recovery_status"
+	err = os.WriteFile(recoveryFile, []byte(recovery1), 0640)
+	require.NoError(t, err)
+
+	m := &Lustre2{
+		OstProcfiles:        []string{obddir + "/*/stats"},
+		RecoveryStatusFiles: []string{obddir + "/*/recovery_status"},
+		EmitRates:           true,
+	}
+
+	origNow := now
+	defer func() { now = origNow }()
+
+	start := time.Unix(1600000000, 0)
+	now = func() time.Time { return start }
+
+	var acc1 testutil.Accumulator
+	err = m.Gather(&acc1)
+	require.NoError(t, err)
+
+	tags := map[string]string{"name": ostName}
+
+	// First Gather has no prior snapshot to diff against, so no rate
+	// field is emitted yet.
+	acc1.AssertContainsTaggedFields(t, "lustre2",
+		map[string]interface{}{
+			"open": uint64(100),
+		}, tags)
+
+	err = os.WriteFile(statsFile, []byte(data2), 0640)
+	require.NoError(t, err)
+	err = os.WriteFile(recoveryFile, []byte(recovery2), 0640)
+	require.NoError(t, err)
+	now = func() time.Time { return start.Add(10 * time.Second) }
+
+	var acc2 testutil.Accumulator
+	err = m.Gather(&acc2)
+	require.NoError(t, err)
+
+	acc2.AssertContainsTaggedFields(t, "lustre2",
+		map[string]interface{}{
+			"open":         uint64(600),
+			"open_per_sec": float64(50),
+		}, tags)
+
+	// recovery_start is a timestamp and connected_clients is a gauge,
+	// neither of which makes sense as a rate, so lustre2_health is
+	// excluded from rate computation entirely - even req_replayed, which
+	// is itself a genuine counter, gets no _per_sec field.
+	acc2.AssertContainsTaggedFields(t, "lustre2_health",
+		map[string]interface{}{
+			"recovery_start":    uint64(1600000010),
+			"time_remaining":    uint64(0),
+			"connected_clients": uint64(12),
+			"req_replayed":      uint64(600),
+		},
+		map[string]string{"name": ostName, "status": "COMPLETE"})
+}